@@ -0,0 +1,80 @@
+// +build android
+
+package anet
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ifaceCache memoizes the last full interfaceTable(0) result so that
+// back-to-back Interfaces, InterfaceByIndex and InterfaceByName calls
+// within ttl share one netlink round-trip. It is disabled (ttl == 0)
+// by default, matching the un-cached behavior of earlier versions.
+type ifaceCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	at  time.Time
+	ift []net.Interface
+}
+
+var globalIfaceCache ifaceCache
+
+// SetCacheTTL enables (ttl > 0) or disables (ttl <= 0) memoization of
+// the interface table used by Interfaces, InterfaceByIndex and
+// InterfaceByName. When a Watch subscription is active, the cache is
+// additionally invalidated on any relevant netlink event rather than
+// waiting out ttl. Changing the TTL drops whatever is currently
+// cached.
+func SetCacheTTL(ttl time.Duration) {
+	globalIfaceCache.mu.Lock()
+	defer globalIfaceCache.mu.Unlock()
+	globalIfaceCache.ttl = ttl
+	globalIfaceCache.ift = nil
+}
+
+func (c *ifaceCache) get() ([]net.Interface, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 || c.ift == nil || time.Since(c.at) > c.ttl {
+		return nil, false
+	}
+	return cloneInterfaces(c.ift), true
+}
+
+func (c *ifaceCache) set(ift []net.Interface) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	c.ift = cloneInterfaces(ift)
+	c.at = time.Now()
+}
+
+func (c *ifaceCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ift = nil
+}
+
+func invalidateIfaceCache() {
+	globalIfaceCache.invalidate()
+}
+
+// cloneInterfaces returns a defensive copy so that cached entries,
+// and the net.Interface values handed back to callers, can't be
+// mutated through one another.
+func cloneInterfaces(ift []net.Interface) []net.Interface {
+	cp := make([]net.Interface, len(ift))
+	copy(cp, ift)
+	for i := range cp {
+		if cp[i].HardwareAddr != nil {
+			ha := make(net.HardwareAddr, len(cp[i].HardwareAddr))
+			copy(ha, cp[i].HardwareAddr)
+			cp[i].HardwareAddr = ha
+		}
+	}
+	return cp
+}
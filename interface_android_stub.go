@@ -24,8 +24,6 @@ var (
 	errNoSuchMulticastInterface = errors.New("no such multicast network interface")
 )
 
-type ifReq [40]byte
-
 // Interfaces returns a list of the system's network interfaces.
 func Interfaces() ([]net.Interface, error) {
 	if androidApiLevel() < android11ApiLevel {
@@ -73,6 +71,11 @@ func InterfaceByIndex(index int) (*net.Interface, error) {
 	if index <= 0 {
 		return nil, &net.OpError{Op: "route", Net: "ip+net", Source: nil, Addr: nil, Err: errInvalidInterfaceIndex}
 	}
+	if cached, ok := globalIfaceCache.get(); ok {
+		if ifi, err := interfaceByIndex(cached, index); err == nil {
+			return ifi, nil
+		}
+	}
 	ift, err := interfaceTable(index)
 	if err != nil {
 		return nil, &net.OpError{Op: "route", Net: "ip+net", Source: nil, Addr: nil, Err: err}
@@ -160,8 +163,19 @@ var zoneCacheX = &ipv6ZoneCache{}
 // If the ifindex is zero, interfaceTable returns mappings of all
 // network interfaces. Otherwise it returns a mapping of a specific
 // interface.
+//
+// This mirrors upstream Go's net.interfaceTable on Linux: it walks
+// RTM_NEWLINK messages rather than RTM_NEWADDR so that interfaces
+// without any assigned address are still reported, and so that
+// HardwareAddr can be populated from IFLA_ADDRESS.
 func interfaceTable(ifindex int) ([]net.Interface, error) {
-	tab, err := NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if ifindex == 0 {
+		if ift, ok := globalIfaceCache.get(); ok {
+			return ift, nil
+		}
+	}
+
+	tab, err := NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
 	if err != nil {
 		return nil, os.NewSyscallError("netlinkrib", err)
 	}
@@ -171,56 +185,80 @@ func interfaceTable(ifindex int) ([]net.Interface, error) {
 	}
 
 	var ift []net.Interface
-	im := make(map[uint32]struct{})
 loop:
 	for _, m := range msgs {
 		switch m.Header.Type {
 		case syscall.NLMSG_DONE:
 			break loop
-		case syscall.RTM_NEWADDR:
-			ifam := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
-			if _, ok := im[ifam.Index]; ok {
-				continue
-			} else {
-				im[ifam.Index] = struct{}{}
-			}
-
-			if ifindex == 0 || ifindex == int(ifam.Index) {
-				ifi := newLink(ifam)
+		case syscall.RTM_NEWLINK:
+			ifim := (*syscall.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+			if ifindex == 0 || ifindex == int(ifim.Index) {
+				attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+				if err != nil {
+					return nil, os.NewSyscallError("parsenetlinkrouteattr", err)
+				}
+				ifi := newLink(ifim, attrs)
 				if ifi != nil {
 					ift = append(ift, *ifi)
 				}
-				if ifindex == int(ifam.Index) {
+				if ifindex == int(ifim.Index) {
 					break loop
 				}
 			}
 		}
 	}
 
+	if ifindex == 0 {
+		globalIfaceCache.set(ift)
+	}
 	return ift, nil
 }
 
-func newLink(ifam *syscall.IfAddrmsg) *net.Interface {
-	ift := &net.Interface{Index: int(ifam.Index)}
+// ARPHRD_* values from linux/if_arp.h for IP tunnel link types. On
+// these, IFLA_ADDRESS carries the tunnel's local/remote IP address,
+// not a MAC, and must not be assigned to HardwareAddr.
+const (
+	sysARPHardwareIPv4IPv4 = 768 // ARPHRD_TUNNEL
+	sysARPHardwareIPv6IPv6 = 769 // ARPHRD_TUNNEL6
+	sysARPHardwareIPv6IPv4 = 776 // ARPHRD_SIT
+	sysARPHardwareGREIPv4  = 778 // ARPHRD_IPGRE
+	sysARPHardwareGREIPv6  = 823 // ARPHRD_IP6GRE
+)
 
-	name, err := indexToName(ifam.Index)
-	if err != nil {
-		return nil
+func newLink(ifim *syscall.IfInfomsg, attrs []syscall.NetlinkRouteAttr) *net.Interface {
+	ifi := &net.Interface{Index: int(ifim.Index), Flags: linkFlags(ifim.Flags)}
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case syscall.IFLA_ADDRESS:
+			// We never return any /32 or /128 IP address prefix on
+			// any IP tunnel interface as the hardware address.
+			switch ifim.Type {
+			case sysARPHardwareIPv4IPv4, sysARPHardwareIPv6IPv6, sysARPHardwareIPv6IPv4, sysARPHardwareGREIPv4, sysARPHardwareGREIPv6:
+				continue
+			}
+			// The kernel can report an all-zero address for
+			// interfaces that don't have one; skip those, as
+			// upstream does.
+			var nonzero bool
+			for _, b := range a.Value {
+				if b != 0 {
+					nonzero = true
+					break
+				}
+			}
+			if nonzero {
+				ifi.HardwareAddr = a.Value[:]
+			}
+		case syscall.IFLA_IFNAME:
+			ifi.Name = string(bytes.Trim(a.Value[:], "\x00"))
+		case syscall.IFLA_MTU:
+			ifi.MTU = int(*(*uint32)(unsafe.Pointer(&a.Value[:4][0])))
+		}
 	}
-	ift.Name = name
-
-	mtu, err := nameToMTU(name)
-	if err != nil {
+	if ifi.Name == "" {
 		return nil
 	}
-	ift.MTU = mtu
-
-	flags, err := nameToFlags(name)
-	if err != nil {
-		return nil
-	}
-	ift.Flags = flags
-	return ift
+	return ifi
 }
 
 func linkFlags(rawFlags uint32) net.Flags {
@@ -333,72 +371,3 @@ func interfaceByIndex(ift []net.Interface, index int) (*net.Interface, error) {
 	}
 	return nil, errNoSuchInterface
 }
-
-func ioctl(fd int, req uint, arg unsafe.Pointer) error {
-	_, _, e1 := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
-	if e1 != 0 {
-		return e1
-	}
-	return nil
-}
-
-func indexToName(index uint32) (string, error) {
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
-	if err != nil {
-		return "", err
-	}
-	defer syscall.Close(fd)
-
-	var ifr ifReq
-	*(*uint32)(unsafe.Pointer(&ifr[syscall.IFNAMSIZ])) = index
-	err = ioctl(fd, syscall.SIOCGIFNAME, unsafe.Pointer(&ifr[0]))
-	if err != nil {
-		return "", err
-	}
-
-	return string(bytes.Trim(ifr[:syscall.IFNAMSIZ], "\x00")), nil
-}
-
-func nameToMTU(name string) (int, error) {
-	// Leave room for terminating NULL byte.
-	if len(name) >= syscall.IFNAMSIZ {
-		return -1, syscall.EINVAL
-	}
-
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
-	if err != nil {
-		return -1, err
-	}
-	defer syscall.Close(fd)
-
-	var ifr ifReq
-	copy(ifr[:], name)
-	err = ioctl(fd, syscall.SIOCGIFMTU, unsafe.Pointer(&ifr[0]))
-	if err != nil {
-		return -1, err
-	}
-
-	return int(*(*int32)(unsafe.Pointer(&ifr[syscall.IFNAMSIZ]))), nil
-}
-
-func nameToFlags(name string) (net.Flags, error) {
-	// Leave room for terminating NULL byte.
-	if len(name) >= syscall.IFNAMSIZ {
-		return 0, syscall.EINVAL
-	}
-
-	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
-	if err != nil {
-		return 0, err
-	}
-	defer syscall.Close(fd)
-
-	var ifr ifReq
-	copy(ifr[:], name)
-	err = ioctl(fd, syscall.SIOCGIFFLAGS, unsafe.Pointer(&ifr[0]))
-	if err != nil {
-		return 0, err
-	}
-
-	return linkFlags(*(*uint32)(unsafe.Pointer(&ifr[syscall.IFNAMSIZ]))), nil
-}
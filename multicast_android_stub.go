@@ -0,0 +1,126 @@
+// +build android
+
+package anet
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+)
+
+// InterfaceMulticastAddrs returns a list of the multicast, joined
+// group addresses for the given interface.
+//
+// net.Interface.MulticastAddrs relies on netlink/getifaddrs, which are
+// unavailable on Android 11+ for the same reason Interfaces is (see
+// interface_android_stub.go). Instead this parses /proc/net/igmp and
+// /proc/net/igmp6, the same source the standard library's Linux
+// implementation reads from.
+func InterfaceMulticastAddrs(ifi *net.Interface) ([]net.Addr, error) {
+	if ifi == nil {
+		return nil, &net.OpError{Op: "route", Net: "ip+net", Source: nil, Addr: nil, Err: errInvalidInterface}
+	}
+
+	if androidApiLevel() < android11ApiLevel {
+		return ifi.MulticastAddrs()
+	}
+
+	var ifat []net.Addr
+	ifat = append(ifat, parseProcNetIGMP("/proc/net/igmp", ifi)...)
+	ifat = append(ifat, parseProcNetIGMP6("/proc/net/igmp6", ifi)...)
+	return ifat, nil
+}
+
+// MulticastAddrs returns a list of the multicast, joined group
+// addresses across all of the system's network interfaces.
+func MulticastAddrs() ([]net.Addr, error) {
+	ift, err := Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifat []net.Addr
+	for i := range ift {
+		ifmat, err := InterfaceMulticastAddrs(&ift[i])
+		if err != nil {
+			return nil, err
+		}
+		ifat = append(ifat, ifmat...)
+	}
+	return ifat, nil
+}
+
+// parseProcNetIGMP parses path (normally /proc/net/igmp), returning
+// the IPv4 multicast addresses joined on ifi. Each interface's
+// addresses follow a header line naming the interface; address lines
+// hold the group address as hex, little-endian. path is a parameter
+// so this can be exercised against a fixture file in tests.
+func parseProcNetIGMP(path string, ifi *net.Interface) []net.Addr {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer fd.Close()
+
+	var ifat []net.Addr
+	var name string
+	sc := bufio.NewScanner(fd)
+	sc.Scan() // skip the column header
+	for sc.Scan() {
+		l := sc.Text()
+		if l == "" {
+			continue
+		}
+		f := strings.Fields(l)
+		switch {
+		case l[0] != ' ' && l[0] != '\t':
+			if len(f) > 1 {
+				name = f[1]
+			}
+		case len(f) == 4:
+			if name != ifi.Name {
+				continue
+			}
+			b, err := hex.DecodeString(f[0])
+			if err != nil || len(b) != 4 {
+				continue
+			}
+			ifat = append(ifat, &net.IPAddr{IP: net.IPv4(b[3], b[2], b[1], b[0])})
+		}
+	}
+	return ifat
+}
+
+// parseProcNetIGMP6 parses path (normally /proc/net/igmp6), returning
+// the IPv6 multicast addresses joined on ifi. Each row has the form
+// "<index> <name> <ip6> ...". path is a parameter so this can be
+// exercised against a fixture file in tests.
+func parseProcNetIGMP6(path string, ifi *net.Interface) []net.Addr {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer fd.Close()
+
+	var ifat []net.Addr
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 3 {
+			continue
+		}
+		if f[1] != ifi.Name {
+			continue
+		}
+		b, err := hex.DecodeString(f[2])
+		if err != nil || len(b) != net.IPv6len {
+			continue
+		}
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, b)
+		ifat = append(ifat, &net.IPAddr{IP: ip})
+	}
+	return ifat
+}
@@ -0,0 +1,239 @@
+// +build android
+
+package anet
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// EventKind identifies the kind of change reported by Watch.
+type EventKind int
+
+const (
+	// LinkAdded is delivered when an interface appears. The netlink
+	// watcher also delivers it for every RTM_NEWLINK the kernel sends
+	// about an existing interface (for example a flag change).
+	LinkAdded EventKind = iota
+	// LinkRemoved is delivered when an interface disappears.
+	LinkRemoved
+	// AddrAdded is delivered when an address is assigned to an interface.
+	// The netlink watcher also delivers it for every RTM_NEWADDR the
+	// kernel sends about an address that is already assigned.
+	AddrAdded
+	// AddrRemoved is delivered when an address is removed from an interface.
+	AddrRemoved
+)
+
+// Event describes a single interface or address change reported by Watch.
+type Event struct {
+	Kind      EventKind
+	Interface net.Interface
+	Addr      net.Addr
+}
+
+// watchEventBacklog bounds the number of undelivered Events buffered
+// for a consumer. Events beyond this are dropped; droppedEvents
+// tracks how many, so a slow consumer can't pin memory.
+const watchEventBacklog = 64
+
+var droppedEvents uint64
+
+// DroppedEvents returns the number of Watch events dropped so far
+// because a consumer fell behind.
+func DroppedEvents() uint64 {
+	return atomic.LoadUint64(&droppedEvents)
+}
+
+// Watch subscribes to interface and address changes, delivering them
+// on the returned channel until ctx is done, at which point the
+// channel is closed. The netlink watcher also closes the channel if
+// reading from its socket fails with an error other than a receive
+// timeout or an interrupted call.
+//
+// On Android 11+ this binds an AF_NETLINK/NETLINK_ROUTE socket to
+// RTMGRP_LINK, RTMGRP_IPV4_IFADDR and RTMGRP_IPV6_IFADDR, reusing the
+// same message parsing as interfaceTable/addrTable. On older Android,
+// where that socket is unavailable, it falls back to polling
+// Interfaces and diffing against the previous snapshot; that fallback
+// delivers only LinkAdded and LinkRemoved, never address events.
+func Watch(ctx context.Context) (<-chan Event, error) {
+	if androidApiLevel() < android11ApiLevel {
+		return watchPoll(ctx), nil
+	}
+	return watchNetlink(ctx)
+}
+
+func send(ch chan<- Event, ev Event) {
+	select {
+	case ch <- ev:
+	default:
+		atomic.AddUint64(&droppedEvents, 1)
+	}
+}
+
+// Netlink multicast group bitmask values from linux/rtnetlink.h.
+// These aren't exposed by the standard library's syscall package (only
+// golang.org/x/sys/unix has them), so they're reproduced here.
+const (
+	rtmgrpLink         = 0x1
+	rtmgrpIPv4Ifaddr   = 0x10
+	rtmgrpIPv6Ifaddr   = 0x100
+	watchRecvPollEvery = time.Second
+)
+
+func watchNetlink(ctx context.Context) (<-chan Event, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	// A receive timeout lets the reader goroutine periodically recheck
+	// ctx.Done() without blocking in Recvfrom forever. That in turn
+	// lets the same goroutine own fd for its entire lifetime instead
+	// of a second goroutine closing it out from under a pending
+	// blocking syscall, which can race onto a recycled fd number.
+	tv := syscall.NsecToTimeval(watchRecvPollEvery.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	ch := make(chan Event, watchEventBacklog)
+
+	go func() {
+		defer close(ch)
+		defer syscall.Close(fd)
+		buf := make([]byte, os.Getpagesize())
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+					continue
+				}
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				switch m.Header.Type {
+				case syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+					invalidateIfaceCache()
+					ifim := (*syscall.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+					attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+					if err != nil {
+						continue
+					}
+					ifi := newLink(ifim, attrs)
+					if ifi == nil {
+						continue
+					}
+					kind := LinkAdded
+					if m.Header.Type == syscall.RTM_DELLINK {
+						kind = LinkRemoved
+					}
+					send(ch, Event{Kind: kind, Interface: *ifi})
+				case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+					ifam := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+					attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+					if err != nil {
+						continue
+					}
+					addr := newAddr(ifam, attrs)
+					if addr == nil {
+						continue
+					}
+					kind := AddrAdded
+					if m.Header.Type == syscall.RTM_DELADDR {
+						kind = AddrRemoved
+					}
+					// Resolve the interface from whatever's already
+					// cached rather than re-entering the public
+					// cache-or-syscall InterfaceByIndex path: this
+					// goroutine is the one draining the netlink
+					// socket, and a blocking RTM_GETLINK round trip
+					// per address event here can't be absorbed by
+					// the channel's drop-with-count backpressure.
+					ev := Event{Kind: kind, Addr: addr, Interface: net.Interface{Index: int(ifam.Index)}}
+					if cached, ok := globalIfaceCache.get(); ok {
+						if ifi, err := interfaceByIndex(cached, int(ifam.Index)); err == nil {
+							ev.Interface = *ifi
+						}
+					}
+					invalidateIfaceCache()
+					send(ch, ev)
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// watchPollInterval is how often the fallback watcher re-lists
+// interfaces to look for changes.
+const watchPollInterval = 2 * time.Second
+
+func watchPoll(ctx context.Context) <-chan Event {
+	ch := make(chan Event, watchEventBacklog)
+
+	go func() {
+		defer close(ch)
+		prev, _ := Interfaces()
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cur, err := Interfaces()
+				if err != nil {
+					continue
+				}
+				diffLinks(ch, prev, cur)
+				prev = cur
+			}
+		}
+	}()
+
+	return ch
+}
+
+func diffLinks(ch chan<- Event, prev, cur []net.Interface) {
+	byIndex := make(map[int]net.Interface, len(prev))
+	for _, ifi := range prev {
+		byIndex[ifi.Index] = ifi
+	}
+	seen := make(map[int]bool, len(cur))
+	for _, ifi := range cur {
+		seen[ifi.Index] = true
+		if _, ok := byIndex[ifi.Index]; !ok {
+			send(ch, Event{Kind: LinkAdded, Interface: ifi})
+		}
+	}
+	for _, ifi := range prev {
+		if !seen[ifi.Index] {
+			send(ch, Event{Kind: LinkRemoved, Interface: ifi})
+		}
+	}
+}